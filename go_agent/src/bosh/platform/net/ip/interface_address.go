@@ -0,0 +1,7 @@
+package ip
+
+// InterfaceAddress is the (possibly lazily resolved) IP address of a network interface.
+type InterfaceAddress interface {
+	GetInterfaceName() string
+	GetIP() (string, error)
+}
@@ -0,0 +1,6 @@
+package ip
+
+// InterfaceAddressResolver looks up the IP address currently assigned to an interface.
+type InterfaceAddressResolver interface {
+	GetIP(interfaceName string) (string, error)
+}
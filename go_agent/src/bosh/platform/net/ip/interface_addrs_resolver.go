@@ -0,0 +1,33 @@
+package ip
+
+import (
+	"regexp"
+
+	bosherr "bosh/errors"
+	boshsys "bosh/system"
+)
+
+var ifaceAddrRegex = regexp.MustCompile(`inet (\d+\.\d+\.\d+\.\d+)/\d+`)
+
+// systemInterfaceAddressResolver resolves an interface's address via `ip addr show`.
+type systemInterfaceAddressResolver struct {
+	cmdRunner boshsys.CmdRunner
+}
+
+func NewSystemInterfaceAddressResolver(cmdRunner boshsys.CmdRunner) InterfaceAddressResolver {
+	return systemInterfaceAddressResolver{cmdRunner: cmdRunner}
+}
+
+func (r systemInterfaceAddressResolver) GetIP(interfaceName string) (string, error) {
+	stdout, _, _, err := r.cmdRunner.RunCommand("ip", "-o", "-f", "inet", "addr", "show", interfaceName)
+	if err != nil {
+		return "", bosherr.WrapError(err, "Running ip addr show")
+	}
+
+	matches := ifaceAddrRegex.FindStringSubmatch(stdout)
+	if len(matches) == 0 {
+		return "", bosherr.New("No address found for interface %s", interfaceName)
+	}
+
+	return matches[1], nil
+}
@@ -0,0 +1,46 @@
+package ip
+
+import (
+	"errors"
+	"testing"
+
+	assert "github.com/stretchr/testify/assert"
+)
+
+type fakeCmdRunner struct {
+	stdout string
+	err    error
+}
+
+func (r fakeCmdRunner) RunCommand(cmd string, args ...string) (string, string, int, error) {
+	return r.stdout, "", 0, r.err
+}
+
+func (r fakeCmdRunner) CommandExists(cmd string) bool {
+	return true
+}
+
+func TestSystemInterfaceAddressResolverParsesIPFromIPAddrShow(t *testing.T) {
+	stdout := `1: eth0    inet 10.0.0.5/24 brd 10.0.0.255 scope global eth0\       valid_lft forever preferred_lft forever`
+	resolver := NewSystemInterfaceAddressResolver(fakeCmdRunner{stdout: stdout})
+
+	ip, err := resolver.GetIP("eth0")
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.5", ip)
+}
+
+func TestSystemInterfaceAddressResolverReturnsErrorWhenNoAddressFound(t *testing.T) {
+	resolver := NewSystemInterfaceAddressResolver(fakeCmdRunner{stdout: "1: eth0    <NO-CARRIER,BROADCAST,MULTICAST,UP>"})
+
+	_, err := resolver.GetIP("eth0")
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "eth0")
+	}
+}
+
+func TestSystemInterfaceAddressResolverReturnsErrorWhenCommandFails(t *testing.T) {
+	resolver := NewSystemInterfaceAddressResolver(fakeCmdRunner{err: errors.New("exit status 1")})
+
+	_, err := resolver.GetIP("eth0")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,57 @@
+package ip
+
+import (
+	"time"
+
+	bosherr "bosh/errors"
+)
+
+// ResolvingInterfaceAddress polls a resolver until it returns an address or the timeout elapses.
+type ResolvingInterfaceAddress struct {
+	interfaceName string
+	resolver      InterfaceAddressResolver
+
+	retryDelay time.Duration
+	timeout    time.Duration
+}
+
+func NewResolvingInterfaceAddress(interfaceName string, resolver InterfaceAddressResolver) ResolvingInterfaceAddress {
+	return NewResolvingInterfaceAddressWithTiming(interfaceName, resolver, 100*time.Millisecond, time.Minute)
+}
+
+func NewResolvingInterfaceAddressWithTiming(
+	interfaceName string,
+	resolver InterfaceAddressResolver,
+	retryDelay time.Duration,
+	timeout time.Duration,
+) ResolvingInterfaceAddress {
+	return ResolvingInterfaceAddress{
+		interfaceName: interfaceName,
+		resolver:      resolver,
+
+		retryDelay: retryDelay,
+		timeout:    timeout,
+	}
+}
+
+func (a ResolvingInterfaceAddress) GetInterfaceName() string {
+	return a.interfaceName
+}
+
+func (a ResolvingInterfaceAddress) GetIP() (string, error) {
+	deadline := time.Now().Add(a.timeout)
+
+	var lastErr error
+
+	for time.Now().Before(deadline) {
+		ip, err := a.resolver.GetIP(a.interfaceName)
+		if err == nil {
+			return ip, nil
+		}
+
+		lastErr = err
+		time.Sleep(a.retryDelay)
+	}
+
+	return "", bosherr.WrapError(lastErr, "Waiting for %s to have an address", a.interfaceName)
+}
@@ -0,0 +1,72 @@
+package ip
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/assert"
+)
+
+type fakeInterfaceAddressResolver struct {
+	results []fakeResolverResult
+	calls   int
+}
+
+type fakeResolverResult struct {
+	ip  string
+	err error
+}
+
+func (r *fakeInterfaceAddressResolver) GetIP(interfaceName string) (string, error) {
+	result := r.results[r.calls]
+	if r.calls < len(r.results)-1 {
+		r.calls++
+	}
+	return result.ip, result.err
+}
+
+func TestResolvingInterfaceAddressGetIPSucceedsImmediately(t *testing.T) {
+	resolver := &fakeInterfaceAddressResolver{
+		results: []fakeResolverResult{{ip: "10.0.0.5"}},
+	}
+
+	address := NewResolvingInterfaceAddressWithTiming("eth0", resolver, time.Millisecond, time.Second)
+
+	ip, err := address.GetIP()
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.5", ip)
+	assert.Equal(t, 0, resolver.calls)
+}
+
+func TestResolvingInterfaceAddressGetIPRetriesUntilSuccess(t *testing.T) {
+	notReadyErr := errors.New("no address found")
+	resolver := &fakeInterfaceAddressResolver{
+		results: []fakeResolverResult{
+			{err: notReadyErr},
+			{err: notReadyErr},
+			{ip: "10.0.0.5"},
+		},
+	}
+
+	address := NewResolvingInterfaceAddressWithTiming("eth0", resolver, time.Millisecond, time.Second)
+
+	ip, err := address.GetIP()
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.5", ip)
+	assert.Equal(t, 2, resolver.calls)
+}
+
+func TestResolvingInterfaceAddressGetIPReturnsWrappedErrorOnTimeout(t *testing.T) {
+	notReadyErr := errors.New("no address found")
+	resolver := &fakeInterfaceAddressResolver{
+		results: []fakeResolverResult{{err: notReadyErr}},
+	}
+
+	address := NewResolvingInterfaceAddressWithTiming("eth0", resolver, time.Millisecond, 5*time.Millisecond)
+
+	_, err := address.GetIP()
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "eth0")
+	}
+}
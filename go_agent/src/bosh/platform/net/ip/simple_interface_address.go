@@ -0,0 +1,18 @@
+package ip
+
+type SimpleInterfaceAddress struct {
+	interfaceName string
+	ip            string
+}
+
+func NewSimpleInterfaceAddress(interfaceName, ip string) SimpleInterfaceAddress {
+	return SimpleInterfaceAddress{interfaceName: interfaceName, ip: ip}
+}
+
+func (a SimpleInterfaceAddress) GetInterfaceName() string {
+	return a.interfaceName
+}
+
+func (a SimpleInterfaceAddress) GetIP() (string, error) {
+	return a.ip, nil
+}
@@ -0,0 +1,130 @@
+package net
+
+import (
+	"encoding/json"
+
+	bosherr "bosh/errors"
+	boshsys "bosh/system"
+)
+
+const networkStateFilePath = "/var/vcap/bosh/network-state.json"
+
+// NetworkChangeDetector reports which interfaces changed since the last apply.
+type NetworkChangeDetector interface {
+	DetectChanges(staticConfigs []StaticInterfaceConfiguration, dhcpConfigs []DHCPInterfaceConfiguration) ([]StaticInterfaceConfiguration, []DHCPInterfaceConfiguration, error)
+}
+
+type networkChangeDetector struct {
+	fs boshsys.FileSystem
+}
+
+func NewNetworkChangeDetector(fs boshsys.FileSystem) NetworkChangeDetector {
+	return networkChangeDetector{fs: fs}
+}
+
+type networkState struct {
+	StaticConfigs []StaticInterfaceConfiguration
+	DHCPConfigs   []DHCPInterfaceConfiguration
+}
+
+func (d networkChangeDetector) DetectChanges(
+	staticConfigs []StaticInterfaceConfiguration,
+	dhcpConfigs []DHCPInterfaceConfiguration,
+) ([]StaticInterfaceConfiguration, []DHCPInterfaceConfiguration, error) {
+	previousState, err := d.loadState()
+	if err != nil {
+		return nil, nil, bosherr.WrapError(err, "Loading previous network state")
+	}
+
+	previousStaticByInterface := map[string]StaticInterfaceConfiguration{}
+	for _, config := range previousState.StaticConfigs {
+		previousStaticByInterface[config.Interface] = config
+	}
+
+	previousDHCPByInterface := map[string]DHCPInterfaceConfiguration{}
+	for _, config := range previousState.DHCPConfigs {
+		previousDHCPByInterface[config.Interface] = config
+	}
+
+	var changedStaticConfigs []StaticInterfaceConfiguration
+	for _, config := range staticConfigs {
+		previousConfig, found := previousStaticByInterface[config.Interface]
+		if !found || staticConfigurationChanged(previousConfig, config) {
+			changedStaticConfigs = append(changedStaticConfigs, config)
+		}
+	}
+
+	var changedDHCPConfigs []DHCPInterfaceConfiguration
+	for _, config := range dhcpConfigs {
+		previousConfig, found := previousDHCPByInterface[config.Interface]
+		if !found || previousConfig.Mac != config.Mac {
+			changedDHCPConfigs = append(changedDHCPConfigs, config)
+		}
+	}
+
+	err = d.saveState(networkState{StaticConfigs: staticConfigs, DHCPConfigs: dhcpConfigs})
+	if err != nil {
+		return nil, nil, bosherr.WrapError(err, "Saving network state")
+	}
+
+	return changedStaticConfigs, changedDHCPConfigs, nil
+}
+
+func staticConfigurationChanged(previousConfig, config StaticInterfaceConfiguration) bool {
+	if previousConfig.IP != config.IP ||
+		previousConfig.Netmask != config.Netmask ||
+		previousConfig.Gateway != config.Gateway ||
+		previousConfig.Mac != config.Mac {
+		return true
+	}
+
+	return !stringSlicesEqual(previousConfig.DNS, config.DNS)
+}
+
+func stringSlicesEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (d networkChangeDetector) loadState() (networkState, error) {
+	if !d.fs.FileExists(networkStateFilePath) {
+		return networkState{}, nil
+	}
+
+	contents, err := d.fs.ReadFileString(networkStateFilePath)
+	if err != nil {
+		return networkState{}, bosherr.WrapError(err, "Reading %s", networkStateFilePath)
+	}
+
+	var state networkState
+
+	err = json.Unmarshal([]byte(contents), &state)
+	if err != nil {
+		return networkState{}, bosherr.WrapError(err, "Unmarshalling network state")
+	}
+
+	return state, nil
+}
+
+func (d networkChangeDetector) saveState(state networkState) error {
+	contents, err := json.Marshal(state)
+	if err != nil {
+		return bosherr.WrapError(err, "Marshalling network state")
+	}
+
+	err = d.fs.WriteFile(networkStateFilePath, contents)
+	if err != nil {
+		return bosherr.WrapError(err, "Writing to %s", networkStateFilePath)
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package net
+
+import (
+	"testing"
+
+	assert "github.com/stretchr/testify/assert"
+
+	boshsettings "bosh/settings"
+	fakesys "bosh/system/fakes"
+)
+
+func eth0Static(ip string) StaticInterfaceConfiguration {
+	return StaticInterfaceConfiguration{
+		Network:   boshsettings.Network{IP: ip, Netmask: "255.255.255.0", Gateway: "10.0.0.1", Mac: "aa:bb:cc:dd:ee:00"},
+		Interface: "eth0",
+	}
+}
+
+func TestDetectChangesFirstRunReportsEveryInterfaceChanged(t *testing.T) {
+	fs := &fakesys.FakeFileSystem{}
+	detector := NewNetworkChangeDetector(fs)
+
+	changedStatic, changedDHCP, err := detector.DetectChanges(
+		[]StaticInterfaceConfiguration{eth0Static("10.0.0.2")},
+		[]DHCPInterfaceConfiguration{{Interface: "eth1", Mac: "aa:bb:cc:dd:ee:01"}},
+	)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []StaticInterfaceConfiguration{eth0Static("10.0.0.2")}, changedStatic)
+	assert.Equal(t, []DHCPInterfaceConfiguration{{Interface: "eth1", Mac: "aa:bb:cc:dd:ee:01"}}, changedDHCP)
+}
+
+func TestDetectChangesReportsNothingWhenUnchanged(t *testing.T) {
+	fs := &fakesys.FakeFileSystem{}
+	detector := NewNetworkChangeDetector(fs)
+
+	static := []StaticInterfaceConfiguration{eth0Static("10.0.0.2")}
+	dhcp := []DHCPInterfaceConfiguration{{Interface: "eth1", Mac: "aa:bb:cc:dd:ee:01"}}
+
+	_, _, err := detector.DetectChanges(static, dhcp)
+	assert.NoError(t, err)
+
+	changedStatic, changedDHCP, err := detector.DetectChanges(static, dhcp)
+	assert.NoError(t, err)
+	assert.Empty(t, changedStatic)
+	assert.Empty(t, changedDHCP)
+}
+
+func TestDetectChangesReportsModifiedInterface(t *testing.T) {
+	fs := &fakesys.FakeFileSystem{}
+	detector := NewNetworkChangeDetector(fs)
+
+	_, _, err := detector.DetectChanges([]StaticInterfaceConfiguration{eth0Static("10.0.0.2")}, nil)
+	assert.NoError(t, err)
+
+	changedStatic, _, err := detector.DetectChanges([]StaticInterfaceConfiguration{eth0Static("10.0.0.3")}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []StaticInterfaceConfiguration{eth0Static("10.0.0.3")}, changedStatic)
+}
+
+func TestDetectChangesReportsAddedInterface(t *testing.T) {
+	fs := &fakesys.FakeFileSystem{}
+	detector := NewNetworkChangeDetector(fs)
+
+	eth1 := StaticInterfaceConfiguration{
+		Network:   boshsettings.Network{IP: "10.0.0.4", Netmask: "255.255.255.0"},
+		Interface: "eth1",
+	}
+
+	_, _, err := detector.DetectChanges([]StaticInterfaceConfiguration{eth0Static("10.0.0.2")}, nil)
+	assert.NoError(t, err)
+
+	changedStatic, _, err := detector.DetectChanges([]StaticInterfaceConfiguration{eth0Static("10.0.0.2"), eth1}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []StaticInterfaceConfiguration{eth1}, changedStatic)
+}
+
+func TestDetectChangesRemovedInterfaceIsNotReturned(t *testing.T) {
+	fs := &fakesys.FakeFileSystem{}
+	detector := NewNetworkChangeDetector(fs)
+
+	eth1 := StaticInterfaceConfiguration{
+		Network:   boshsettings.Network{IP: "10.0.0.4", Netmask: "255.255.255.0"},
+		Interface: "eth1",
+	}
+
+	_, _, err := detector.DetectChanges([]StaticInterfaceConfiguration{eth0Static("10.0.0.2"), eth1}, nil)
+	assert.NoError(t, err)
+
+	changedStatic, _, err := detector.DetectChanges([]StaticInterfaceConfiguration{eth0Static("10.0.0.2")}, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, changedStatic)
+}
+
+// Between reboots the kernel can remap MAC addresses to different interface
+// names (e.g. what was eth0 comes up as eth1). Since the snapshot is keyed
+// by interface name, the remapped interface should be treated as new rather
+// than silently reusing eth0's last-applied state.
+func TestDetectChangesHandlesMacAddressRemappingAcrossReboot(t *testing.T) {
+	fs := &fakesys.FakeFileSystem{}
+	detector := NewNetworkChangeDetector(fs)
+
+	beforeReboot := StaticInterfaceConfiguration{
+		Network:   boshsettings.Network{IP: "10.0.0.2", Netmask: "255.255.255.0", Mac: "aa:bb:cc:dd:ee:00"},
+		Interface: "eth0",
+	}
+
+	_, _, err := detector.DetectChanges([]StaticInterfaceConfiguration{beforeReboot}, nil)
+	assert.NoError(t, err)
+
+	afterReboot := StaticInterfaceConfiguration{
+		Network:   boshsettings.Network{IP: "10.0.0.2", Netmask: "255.255.255.0", Mac: "aa:bb:cc:dd:ee:00"},
+		Interface: "eth1",
+	}
+
+	changedStatic, _, err := detector.DetectChanges([]StaticInterfaceConfiguration{afterReboot}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []StaticInterfaceConfiguration{afterReboot}, changedStatic)
+}
@@ -3,28 +3,25 @@ package net
 import (
 	"bytes"
 	"path/filepath"
-	"regexp"
 	"strings"
 	"text/template"
 	"time"
 
 	bosherr "bosh/errors"
 	boshlog "bosh/logger"
+	boship "bosh/platform/net/ip"
 	boshsettings "bosh/settings"
 	boshsys "bosh/system"
 )
 
 const ubuntuNetManagerLogTag = "ubuntuNetManager"
 
-var (
-	ifupVersion07Regex = regexp.MustCompile(`ifup version 0\.7`)
-)
-
 type ubuntuNetManager struct {
 	arpWaitInterval time.Duration
 	cmdRunner       boshsys.CmdRunner
 	fs              boshsys.FileSystem
 	logger          boshlog.Logger
+	changeDetector  NetworkChangeDetector
 }
 
 func NewUbuntuNetManager(
@@ -38,54 +35,149 @@ func NewUbuntuNetManager(
 		cmdRunner:       cmdRunner,
 		fs:              fs,
 		logger:          logger,
+		changeDetector:  NewNetworkChangeDetector(fs),
 	}
 }
 
+type StaticInterfaceConfiguration struct {
+	boshsettings.Network
+	Interface         string
+	NetworkIP         string
+	Broadcast         string
+	HasDefaultGateway bool
+}
+
+type DHCPInterfaceConfiguration struct {
+	Interface string
+	Mac       string
+}
+
 func (net ubuntuNetManager) getDNSServers(networks boshsettings.Networks) []string {
 	var dnsServers []string
 	dnsNetwork, found := networks.DefaultNetworkFor("dns")
 	if found {
-		for i := len(dnsNetwork.DNS) - 1; i >= 0; i-- {
-			dnsServers = append(dnsServers, dnsNetwork.DNS[i])
-		}
+		dnsServers = append(dnsServers, dnsNetwork.DNS...)
 	}
 	return dnsServers
 }
 
-func (net ubuntuNetManager) SetupDhcp(networks boshsettings.Networks) error {
-	dnsServers := net.getDNSServers(networks)
-	dnsServersList := strings.Join(dnsServers, ", ")
-	buffer := bytes.NewBuffer([]byte{})
-	t := template.Must(template.New("dhcp-config").Parse(ubuntuDHCPConfigTemplate))
+func (net ubuntuNetManager) SetupNetworking(networks boshsettings.Networks, errCh chan error) error {
+	staticConfigs, dhcpConfigs, err := net.buildInterfaceConfigurations(networks)
+	if err != nil {
+		return bosherr.WrapError(err, "Building interface configurations")
+	}
 
-	err := t.Execute(buffer, dnsServersList)
+	_, err = net.writeNetworkInterfaces(staticConfigs, dhcpConfigs)
 	if err != nil {
-		return bosherr.WrapError(err, "Generating config from template")
+		return bosherr.WrapError(err, "Writing network interfaces")
 	}
 
-	dhclientConfigFile := net.dhclientConfigFile()
-	written, err := net.fs.ConvergeFileContents(dhclientConfigFile, buffer.Bytes())
+	changedStaticConfigs, changedDHCPConfigs, err := net.changeDetector.DetectChanges(staticConfigs, dhcpConfigs)
 	if err != nil {
-		return bosherr.WrapError(err, "Writing to %s", dhclientConfigFile)
+		return bosherr.WrapError(err, "Detecting network changes")
 	}
 
-	if written {
-		args := net.restartNetworkArguments()
+	// Gated on the diff, not on whether the interfaces file was rewritten:
+	// a DNS-only change never touches that file.
+	net.restartNetworkingInterfaces(changedStaticConfigs)
+
+	err = net.writeResolvConf(networks)
+	if err != nil {
+		return bosherr.WrapError(err, "Writing resolv.conf")
+	}
 
-		_, _, _, err := net.cmdRunner.RunCommand("ifdown", args...)
+	if len(dhcpConfigs) > 0 {
+		dhclientConfigWritten, err := net.writeDhclientConfig(networks)
 		if err != nil {
-			net.logger.Info(ubuntuNetManagerLogTag, "Ignoring ifdown failure: %#v", err)
+			return bosherr.WrapError(err, "Setting up dhclient")
 		}
 
-		_, _, _, err = net.cmdRunner.RunCommand("ifup", args...)
-		if err != nil {
-			net.logger.Info(ubuntuNetManagerLogTag, "Ignoring ifup failure: %#v", err)
+		// dhclient.conf applies to every DHCP interface, not just the changed ones.
+		dhcpConfigsToRestart := changedDHCPConfigs
+		if dhclientConfigWritten {
+			dhcpConfigsToRestart = dhcpConfigs
 		}
+
+		net.restartDhcpInterfaces(dhcpConfigsToRestart)
 	}
 
+	go net.gratuitiousArp(net.interfaceAddresses(staticConfigs, dhcpConfigs), errCh)
+
 	return nil
 }
 
+func (net ubuntuNetManager) buildInterfaceConfigurations(networks boshsettings.Networks) ([]StaticInterfaceConfiguration, []DHCPInterfaceConfiguration, error) {
+	var staticConfigs []StaticInterfaceConfiguration
+	var dhcpConfigs []DHCPInterfaceConfiguration
+
+	macAddresses, err := net.detectMacAddresses()
+	if err != nil {
+		return nil, nil, bosherr.WrapError(err, "Detecting mac addresses")
+	}
+
+	for _, aNet := range networks {
+		interfaceName := macAddresses[aNet.Mac]
+
+		if aNet.IP == "" {
+			dhcpConfigs = append(dhcpConfigs, DHCPInterfaceConfiguration{
+				Interface: interfaceName,
+				Mac:       aNet.Mac,
+			})
+			continue
+		}
+
+		network, broadcast, err := boshsys.CalculateNetworkAndBroadcast(aNet.IP, aNet.Netmask)
+		if err != nil {
+			return nil, nil, bosherr.WrapError(err, "Calculating network and broadcast")
+		}
+
+		staticConfigs = append(staticConfigs, StaticInterfaceConfiguration{
+			Network:           aNet,
+			Interface:         interfaceName,
+			NetworkIP:         network,
+			Broadcast:         broadcast,
+			HasDefaultGateway: true,
+		})
+	}
+
+	return staticConfigs, dhcpConfigs, nil
+}
+
+func (net ubuntuNetManager) interfaceAddresses(staticConfigs []StaticInterfaceConfiguration, dhcpConfigs []DHCPInterfaceConfiguration) []boship.InterfaceAddress {
+	var addresses []boship.InterfaceAddress
+
+	for _, config := range staticConfigs {
+		addresses = append(addresses, boship.NewSimpleInterfaceAddress(config.Interface, config.IP))
+	}
+
+	resolver := boship.NewSystemInterfaceAddressResolver(net.cmdRunner)
+	for _, config := range dhcpConfigs {
+		addresses = append(addresses, boship.NewResolvingInterfaceAddress(config.Interface, resolver))
+	}
+
+	return addresses
+}
+
+func (net ubuntuNetManager) writeDhclientConfig(networks boshsettings.Networks) (bool, error) {
+	dnsServers := net.getDNSServers(networks)
+	dnsServersList := strings.Join(dnsServers, ", ")
+	buffer := bytes.NewBuffer([]byte{})
+	t := template.Must(template.New("dhcp-config").Parse(ubuntuDHCPConfigTemplate))
+
+	err := t.Execute(buffer, dnsServersList)
+	if err != nil {
+		return false, bosherr.WrapError(err, "Generating config from template")
+	}
+
+	dhclientConfigFile := net.dhclientConfigFile()
+	written, err := net.fs.ConvergeFileContents(dhclientConfigFile, buffer.Bytes())
+	if err != nil {
+		return false, bosherr.WrapError(err, "Writing to %s", dhclientConfigFile)
+	}
+
+	return written, nil
+}
+
 // DHCP Config file - /etc/dhcp3/dhclient.conf
 // Ubuntu 14.04 accepts several DNS as a list in a single prepend directive
 const ubuntuDHCPConfigTemplate = `# Generated by bosh-agent
@@ -102,38 +194,26 @@ request subnet-mask, broadcast-address, time-offset, routers,
 prepend domain-name-servers {{ . }};
 `
 
-func (net ubuntuNetManager) SetupManualNetworking(networks boshsettings.Networks, errCh chan error) error {
-	modifiedNetworks, written, err := net.writeNetworkInterfaces(networks)
-	if err != nil {
-		return bosherr.WrapError(err, "Writing network interfaces")
-	}
-
-	if written {
-		net.restartNetworkingInterfaces(modifiedNetworks)
-	}
-
-	err = net.writeResolvConf(networks)
-	if err != nil {
-		return bosherr.WrapError(err, "Writing resolv.conf")
-	}
-
-	go net.gratuitiousArp(modifiedNetworks, errCh)
-
-	return nil
-}
-
 func (net ubuntuNetManager) GetDefaultNetwork() (boshsettings.Network, error) {
 	return boshsettings.Network{}, nil
 }
 
-func (net ubuntuNetManager) gratuitiousArp(networks []customNetwork, errCh chan error) {
+func (net ubuntuNetManager) gratuitiousArp(addresses []boship.InterfaceAddress, errCh chan error) {
 	for i := 0; i < 6; i++ {
-		for _, network := range networks {
-			for !net.fs.FileExists(filepath.Join("/sys/class/net", network.Interface)) {
+		for _, address := range addresses {
+			interfaceName := address.GetInterfaceName()
+
+			for !net.fs.FileExists(filepath.Join("/sys/class/net", interfaceName)) {
 				time.Sleep(100 * time.Millisecond)
 			}
 
-			_, _, _, err := net.cmdRunner.RunCommand("arping", "-c", "1", "-U", "-I", network.Interface, network.IP)
+			ip, err := address.GetIP()
+			if err != nil {
+				net.logger.Info(ubuntuNetManagerLogTag, "Ignoring failure to resolve address of %s: %#v", interfaceName, err)
+				continue
+			}
+
+			_, _, _, err = net.cmdRunner.RunCommand("arping", "-c", "1", "-U", "-I", interfaceName, ip)
 			if err != nil {
 				net.logger.Info(ubuntuNetManagerLogTag, "Ignoring arping failure: %#v", err)
 			}
@@ -147,57 +227,47 @@ func (net ubuntuNetManager) gratuitiousArp(networks []customNetwork, errCh chan
 	}
 }
 
-func (net ubuntuNetManager) writeNetworkInterfaces(networks boshsettings.Networks) ([]customNetwork, bool, error) {
-	var modifiedNetworks []customNetwork
-
-	macAddresses, err := net.detectMacAddresses()
-	if err != nil {
-		return modifiedNetworks, false, bosherr.WrapError(err, "Detecting mac addresses")
-	}
-
-	for _, aNet := range networks {
-		network, broadcast, err := boshsys.CalculateNetworkAndBroadcast(aNet.IP, aNet.Netmask)
-		if err != nil {
-			return modifiedNetworks, false, bosherr.WrapError(err, "Calculating network and broadcast")
-		}
-
-		newNet := customNetwork{
-			aNet,
-			macAddresses[aNet.Mac],
-			network,
-			broadcast,
-			true,
-		}
-		modifiedNetworks = append(modifiedNetworks, newNet)
-	}
-
+func (net ubuntuNetManager) writeNetworkInterfaces(staticConfigs []StaticInterfaceConfiguration, dhcpConfigs []DHCPInterfaceConfiguration) (bool, error) {
 	buffer := bytes.NewBuffer([]byte{})
 	t := template.Must(template.New("network-interfaces").Parse(ubuntuNetworkInterfacesTemplate))
 
-	err = t.Execute(buffer, modifiedNetworks)
+	type templateArg struct {
+		StaticConfigs []StaticInterfaceConfiguration
+		DHCPConfigs   []DHCPInterfaceConfiguration
+	}
+
+	err := t.Execute(buffer, templateArg{staticConfigs, dhcpConfigs})
 	if err != nil {
-		return modifiedNetworks, false, bosherr.WrapError(err, "Generating config from template")
+		return false, bosherr.WrapError(err, "Generating config from template")
 	}
 
 	written, err := net.fs.ConvergeFileContents("/etc/network/interfaces", buffer.Bytes())
 	if err != nil {
-		return modifiedNetworks, false, bosherr.WrapError(err, "Writing to /etc/network/interfaces")
+		return false, bosherr.WrapError(err, "Writing to /etc/network/interfaces")
 	}
 
-	return modifiedNetworks, written, nil
+	return written, nil
 }
 
 const ubuntuNetworkInterfacesTemplate = `# Generated by bosh-agent
 auto lo
 iface lo inet loopback
-{{ range . }}
+{{ range .StaticConfigs }}
 auto {{ .Interface }}
 iface {{ .Interface }} inet static
     address {{ .IP }}
     network {{ .NetworkIP }}
     netmask {{ .Netmask }}
     broadcast {{ .Broadcast }}
-{{ if .HasDefaultGateway }}    gateway {{ .Gateway }}{{ end }}{{ end }}`
+{{ if .HasDefaultGateway }}    gateway {{ .Gateway }}{{ end }}{{ end }}
+{{ range .DHCPConfigs }}
+auto {{ .Interface }}
+iface {{ .Interface }} inet dhcp
+{{ end }}`
+
+type dnsConfigArg struct {
+	DNSServers []string
+}
 
 func (net ubuntuNetManager) writeResolvConf(networks boshsettings.Networks) error {
 	buffer := bytes.NewBuffer([]byte{})
@@ -210,6 +280,20 @@ func (net ubuntuNetManager) writeResolvConf(networks boshsettings.Networks) erro
 		return bosherr.WrapError(err, "Generating config from template")
 	}
 
+	if net.cmdRunner.CommandExists("resolvconf") {
+		err = net.fs.WriteFile("/etc/resolvconf/resolv.conf.d/head", buffer.Bytes())
+		if err != nil {
+			return bosherr.WrapError(err, "Writing to /etc/resolvconf/resolv.conf.d/head")
+		}
+
+		_, _, _, err = net.cmdRunner.RunCommand("resolvconf", "-u")
+		if err != nil {
+			return bosherr.WrapError(err, "Updating resolvconf")
+		}
+
+		return nil
+	}
+
 	err = net.fs.WriteFile("/etc/resolv.conf", buffer.Bytes())
 	if err != nil {
 		return bosherr.WrapError(err, "Writing to /etc/resolv.conf")
@@ -246,40 +330,35 @@ func (net ubuntuNetManager) detectMacAddresses() (map[string]string, error) {
 	return addresses, nil
 }
 
-func (net ubuntuNetManager) restartNetworkingInterfaces(networks []customNetwork) {
-	for _, network := range networks {
-		_, _, _, err := net.cmdRunner.RunCommand("service", "network-interface", "stop", "INTERFACE="+network.Interface)
-		if err != nil {
-			net.logger.Info(ubuntuNetManagerLogTag, "Ignoring network stop failure: %#v", err)
-		}
-
-		_, _, _, err = net.cmdRunner.RunCommand("service", "network-interface", "start", "INTERFACE="+network.Interface)
-		if err != nil {
-			net.logger.Info(ubuntuNetManagerLogTag, "Ignoring network start failure: %#v", err)
-		}
+func (net ubuntuNetManager) restartNetworkingInterfaces(staticConfigs []StaticInterfaceConfiguration) {
+	for _, config := range staticConfigs {
+		net.restartNetworkingInterface(config.Interface)
 	}
 }
 
-func (net ubuntuNetManager) dhclientConfigFile() string {
-	if net.cmdRunner.CommandExists("dhclient3") {
-		// Using dhclient3
-		return "/etc/dhcp3/dhclient.conf"
+func (net ubuntuNetManager) restartDhcpInterfaces(dhcpConfigs []DHCPInterfaceConfiguration) {
+	for _, config := range dhcpConfigs {
+		net.restartNetworkingInterface(config.Interface)
 	}
-
-	return "/etc/dhcp/dhclient.conf"
 }
 
-func (net ubuntuNetManager) restartNetworkArguments() []string {
-	stdout, _, _, err := net.cmdRunner.RunCommand("ifup", "--version")
+func (net ubuntuNetManager) restartNetworkingInterface(interfaceName string) {
+	_, _, _, err := net.cmdRunner.RunCommand("service", "network-interface", "stop", "INTERFACE="+interfaceName)
+	if err != nil {
+		net.logger.Info(ubuntuNetManagerLogTag, "Ignoring network stop failure: %#v", err)
+	}
+
+	_, _, _, err = net.cmdRunner.RunCommand("service", "network-interface", "start", "INTERFACE="+interfaceName)
 	if err != nil {
-		net.logger.Info(ubuntuNetManagerLogTag, "Ignoring ifup version failure: %#v", err)
+		net.logger.Info(ubuntuNetManagerLogTag, "Ignoring network start failure: %#v", err)
 	}
+}
 
-	// Check if command accepts --no-loopback argument
-	// --exclude does not work with ifup > 0.7 which comes in Ubuntu 14.04
-	if ifupVersion07Regex.MatchString(stdout) {
-		return []string{"-a", "--no-loopback"}
+func (net ubuntuNetManager) dhclientConfigFile() string {
+	if net.cmdRunner.CommandExists("dhclient3") {
+		// Using dhclient3
+		return "/etc/dhcp3/dhclient.conf"
 	}
 
-	return []string{"-a", "--exclude=lo"}
+	return "/etc/dhcp/dhclient.conf"
 }
@@ -0,0 +1,112 @@
+package net
+
+import (
+	"testing"
+
+	assert "github.com/stretchr/testify/assert"
+
+	boshlog "bosh/logger"
+	boshsettings "bosh/settings"
+	fakesys "bosh/system/fakes"
+)
+
+func buildUbuntuNetManager() (ubuntuNetManager, *fakesys.FakeFileSystem, *fakesys.FakeCmdRunner) {
+	fs := &fakesys.FakeFileSystem{}
+	cmdRunner := &fakesys.FakeCmdRunner{}
+	logger := boshlog.NewLogger(boshlog.LevelNone)
+
+	manager := NewUbuntuNetManager(fs, cmdRunner, 0, logger)
+	return manager, fs, cmdRunner
+}
+
+func TestGetDNSServersPreservesOrdering(t *testing.T) {
+	manager, _, _ := buildUbuntuNetManager()
+
+	cases := []struct {
+		name     string
+		dns      []string
+		expected []string
+	}{
+		{"single entry", []string{"8.8.8.8"}, []string{"8.8.8.8"}},
+		{"no entries", []string{}, nil},
+		{"multiple entries, non-ascending", []string{"127.0.0.1", "9.9.9.9", "8.8.8.8"}, []string{"127.0.0.1", "9.9.9.9", "8.8.8.8"}},
+	}
+
+	for _, c := range cases {
+		networks := boshsettings.Networks{"bosh": boshsettings.Network{DNS: c.dns}}
+		assert.Equal(t, c.expected, manager.getDNSServers(networks))
+	}
+}
+
+func TestWriteResolvConfPreservesDNSOrdering(t *testing.T) {
+	cases := []struct {
+		name     string
+		dns      []string
+		expected string
+	}{
+		{"single entry", []string{"8.8.8.8"}, "# Generated by bosh-agent\nnameserver 8.8.8.8\n"},
+		{"no entries", []string{}, "# Generated by bosh-agent\n"},
+		{"multiple entries, non-ascending", []string{"127.0.0.1", "9.9.9.9", "8.8.8.8"}, "# Generated by bosh-agent\nnameserver 127.0.0.1\nnameserver 9.9.9.9\nnameserver 8.8.8.8\n"},
+	}
+
+	for _, c := range cases {
+		manager, fs, _ := buildUbuntuNetManager()
+		networks := boshsettings.Networks{"bosh": boshsettings.Network{DNS: c.dns}}
+
+		err := manager.writeResolvConf(networks)
+		assert.NoError(t, err)
+
+		written := fs.GetFileTestStat("/etc/resolv.conf")
+		if assert.NotNil(t, written) {
+			assert.Equal(t, c.expected, string(written.Content))
+		}
+	}
+}
+
+func TestWriteResolvConfUsesResolvconfWhenPresent(t *testing.T) {
+	manager, fs, cmdRunner := buildUbuntuNetManager()
+	cmdRunner.CommandExistsValue = true
+
+	networks := boshsettings.Networks{
+		"bosh": boshsettings.Network{
+			DNS: []string{"8.8.8.8", "9.9.9.9"},
+		},
+	}
+
+	err := manager.writeResolvConf(networks)
+	assert.NoError(t, err)
+
+	written := fs.GetFileTestStat("/etc/resolvconf/resolv.conf.d/head")
+	if assert.NotNil(t, written) {
+		assert.Equal(t, "# Generated by bosh-agent\nnameserver 8.8.8.8\nnameserver 9.9.9.9\n", string(written.Content))
+	}
+
+	assert.Nil(t, fs.GetFileTestStat("/etc/resolv.conf"))
+	assert.Equal(t, [][]string{{"resolvconf", "-u"}}, cmdRunner.RunCommands)
+}
+
+func TestWriteDhclientConfigPreservesDNSOrdering(t *testing.T) {
+	cases := []struct {
+		name     string
+		dns      []string
+		expected string
+	}{
+		{"single entry", []string{"8.8.8.8"}, "prepend domain-name-servers 8.8.8.8;"},
+		{"no entries", []string{}, "prepend domain-name-servers ;"},
+		{"multiple entries, non-ascending", []string{"127.0.0.1", "9.9.9.9", "8.8.8.8"}, "prepend domain-name-servers 127.0.0.1, 9.9.9.9, 8.8.8.8;"},
+	}
+
+	for _, c := range cases {
+		manager, fs, _ := buildUbuntuNetManager()
+		networks := boshsettings.Networks{"bosh": boshsettings.Network{DNS: c.dns}}
+
+		written, err := manager.writeDhclientConfig(networks)
+		assert.NoError(t, err)
+		assert.True(t, written)
+
+		stat := fs.GetFileTestStat("/etc/dhcp/dhclient.conf")
+		if assert.NotNil(t, stat) {
+			assert.Contains(t, string(stat.Content), c.expected)
+		}
+	}
+}